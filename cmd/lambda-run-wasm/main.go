@@ -0,0 +1,82 @@
+// Command lambda-run-wasm loads a module produced by `lambda build` and
+// runs its exported "main" function, so a script compiled to wasm can be
+// run the same way the tree-walking interpreter runs a script file. It
+// resolves the result closure's table index against the module's
+// sidecar debug file (module path + wasm.DebugExt) to print source text
+// comparable to what interp.Print would show for the same result,
+// falling back to the raw closure ref if the debug file is missing.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+
+	"lambda/compile/wasm"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: lambda-run-wasm module.wasm")
+		os.Exit(1)
+	}
+
+	module, err := os.ReadFile(os.Args[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "can't read %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+
+	debug := loadDebug(os.Args[1] + wasm.DebugExt)
+
+	ctx := context.Background()
+	runtime := wazero.NewRuntime(ctx)
+	defer runtime.Close(ctx)
+
+	instance, err := runtime.Instantiate(ctx, module)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "can't instantiate module: %v\n", err)
+		os.Exit(1)
+	}
+
+	results, err := instance.ExportedFunction("main").Call(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "can't run main: %v\n", err)
+		os.Exit(1)
+	}
+	closureRef := uint32(results[0])
+
+	fmt.Printf("result: %s\n", describe(instance, closureRef, debug))
+}
+
+// loadDebug reads the sidecar debug file at path, returning nil (rather
+// than failing the run) if it isn't there, so older modules built before
+// this sidecar existed still run, just without a readable result.
+func loadDebug(path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return wasm.DecodeDebug(data)
+}
+
+// describe resolves closureRef's tableIndex word (the first word of the
+// 8-byte closure record every result is a pointer to) against debug,
+// falling back to the raw closure ref when there's no debug text to
+// show for it.
+func describe(instance api.Module, closureRef uint32, debug []string) string {
+	mem := instance.ExportedMemory("memory")
+	if mem == nil {
+		return fmt.Sprintf("closure ref %d", closureRef)
+	}
+
+	tableIdx, ok := mem.ReadUint32Le(closureRef)
+	if !ok || tableIdx >= uint32(len(debug)) {
+		return fmt.Sprintf("closure ref %d", closureRef)
+	}
+
+	return debug[tableIdx]
+}
@@ -0,0 +1,410 @@
+// Package wasm compiles a lambda-calculus AST (as produced by
+// interp.Treeify) to a standalone WebAssembly module. Every runtime value
+// is a pointer into linear memory to an 8-byte closure record
+// {tableIndex int32, envPtr int32}; Application lowers to a trampoline
+// call through a funcref table, and each Lambda becomes its own wasm
+// function plus, at its construction site, the code that captures its
+// free variables (computed by interp.FreeVars) into a fresh env array.
+package wasm
+
+import (
+	"fmt"
+	"strings"
+
+	"lambda/interp"
+)
+
+// DebugExt is the filename suffix `lambda build` appends to its -o path
+// for the sidecar file holding the debug slice Compile returns, so a
+// separate host process like cmd/lambda-run-wasm can load it back to
+// resolve a result closure's table index to source text.
+const DebugExt = ".debug"
+
+// EncodeDebug serializes a debug slice, as returned by Compile, into the
+// sidecar file format: one entry per line, in table-index order.
+func EncodeDebug(debug []string) []byte {
+	return []byte(strings.Join(debug, "\n") + "\n")
+}
+
+// DecodeDebug parses the sidecar file format EncodeDebug writes.
+func DecodeDebug(data []byte) []string {
+	text := strings.TrimSuffix(string(data), "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// wasm opcodes and type bytes used below (WebAssembly 1.0 / MVP).
+const (
+	opEnd          = 0x0b
+	opCall         = 0x10
+	opCallIndirect = 0x11
+	opLocalGet     = 0x20
+	opLocalSet     = 0x21
+	opGlobalGet    = 0x23
+	opGlobalSet    = 0x24
+	opI32Load      = 0x28
+	opI32Store     = 0x36
+	opI32Const     = 0x41
+	opI32Add       = 0x6a
+
+	valTypeI32  = 0x7f
+	funcTypeTag = 0x60
+
+	allocFuncIdx = 0 // func index 0: alloc(size i32) -> i32
+	applyFuncIdx = 1 // func index 1: apply(closurePtr, argPtr i32) -> i32
+	mainFuncIdx  = 2 // func index 2: main() -> i32
+
+	// closureBaseFuncIdx is the module function index of the first
+	// compiled Lambda body; earlier indices are the fixed runtime funcs.
+	closureBaseFuncIdx = 3
+
+	bumpInit = 16 // leave a little headroom at address 0
+
+	typeAlloc   = 0 // (i32) -> i32
+	typeClosure = 1 // (i32, i32) -> i32, shared by apply and every Lambda body
+	typeMain    = 2 // () -> i32
+)
+
+// frameSlot describes where a Name's value lives within a generated
+// function: either the argument register (isParam) or an offset into the
+// captured environment array pointed to by the env register.
+type frameSlot struct {
+	isParam bool
+	offset  int
+}
+
+type funcCtx struct {
+	paramLocal int // local index holding the argument ref, or -1 for main
+	envLocal   int // local index holding the captured-env pointer, or -1 for main
+	tmp0, tmp1 int
+	frame      map[interp.Name]frameSlot
+}
+
+type genFunc struct {
+	numLocals int // additional i32 locals beyond the function's params
+	body      []byte
+	text      string // interp.Print of the source Lambda, for debugging
+}
+
+type compiler struct {
+	funcs []genFunc // one per compiled Lambda, index == table index
+}
+
+// Compile lowers ast to a complete WebAssembly module. ast must be closed
+// (no free variables) since it is evaluated as the module's exported
+// "main" function.
+//
+// Alongside the module it returns debug, the interp.Print text of each
+// compiled Lambda indexed by its closure table index (the same index a
+// closure ref's tableIndex word names at runtime). A host like
+// cmd/lambda-run-wasm can use it to show a result's source text instead
+// of an opaque linear-memory address, comparable to what the
+// tree-walking interpreter's interp.Print would show for the same
+// closure.
+func Compile(ast interp.Element) (module []byte, debug []string, err error) {
+	if free := interp.FreeVars(ast); len(free) > 0 {
+		return nil, nil, fmt.Errorf("wasm: top-level expression has unbound variable(s): %v", free)
+	}
+
+	c := &compiler{}
+
+	mainCtx := &funcCtx{paramLocal: -1, envLocal: -1, tmp0: 0, tmp1: 1, frame: map[interp.Name]frameSlot{}}
+	mainBody, err := c.emitExpr(ast, mainCtx)
+	if err != nil {
+		return nil, nil, err
+	}
+	mainBody = append(mainBody, opEnd)
+
+	debug = make([]string, len(c.funcs))
+	for i, f := range c.funcs {
+		debug[i] = f.text
+	}
+
+	return c.assemble(mainBody), debug, nil
+}
+
+// emitExpr emits the instructions that push e's resulting ref (a closure
+// pointer) onto the stack, within the function described by ctx.
+func (c *compiler) emitExpr(e interp.Element, ctx *funcCtx) ([]byte, error) {
+	switch v := e.(type) {
+	case interp.NameRef:
+		slot, ok := ctx.frame[v.Name]
+		if !ok {
+			return nil, fmt.Errorf("wasm: unbound variable %q", v.Name)
+		}
+		if slot.isParam {
+			return []byte{opLocalGet, byte(ctx.paramLocal)}, nil
+		}
+		buf := []byte{opLocalGet, byte(ctx.envLocal)}
+		buf = append(buf, opI32Load)
+		buf = append(buf, memarg(uint32(slot.offset*4))...)
+		return buf, nil
+
+	case interp.Lambda:
+		return c.compileLambda(v, ctx)
+
+	case interp.Application:
+		aCode, err := c.emitExpr(v.A, ctx)
+		if err != nil {
+			return nil, err
+		}
+		bCode, err := c.emitExpr(v.B, ctx)
+		if err != nil {
+			return nil, err
+		}
+		buf := append(aCode, bCode...)
+		buf = append(buf, opCall, applyFuncIdx)
+		return buf, nil
+	}
+
+	return nil, fmt.Errorf("wasm: unsupported AST node %T", e)
+}
+
+// compileLambda generates a new wasm function for l's body, registers it
+// in the funcref table, and returns the code (to run in the enclosing
+// function described by outer) that builds a closure referencing it.
+func (c *compiler) compileLambda(l interp.Lambda, outer *funcCtx) ([]byte, error) {
+	free := interp.FreeVars(l)
+
+	tableIdx := len(c.funcs)
+	c.funcs = append(c.funcs, genFunc{}) // reserve the slot before recursing
+
+	inner := &funcCtx{paramLocal: 1, envLocal: 0, tmp0: 2, tmp1: 3, frame: map[interp.Name]frameSlot{}}
+	for idx, fv := range free {
+		inner.frame[fv] = frameSlot{offset: idx}
+	}
+	inner.frame[l.Parameter] = frameSlot{isParam: true}
+
+	body, err := c.emitExpr(l.Expression, inner)
+	if err != nil {
+		return nil, err
+	}
+	body = append(body, opEnd)
+	c.funcs[tableIdx] = genFunc{numLocals: 2, body: body, text: interp.Print(l)}
+
+	var buf []byte
+
+	// envArrPtr := alloc(len(free) * 4); tmp0 = envArrPtr
+	buf = append(buf, opI32Const)
+	buf = append(buf, sleb(int64(len(free)*4))...)
+	buf = append(buf, opCall, allocFuncIdx)
+	buf = append(buf, opLocalSet, byte(outer.tmp0))
+
+	for idx, fv := range free {
+		buf = append(buf, opLocalGet, byte(outer.tmp0))
+		valCode, err := c.emitExpr(interp.NameRef{Name: fv}, outer)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, valCode...)
+		buf = append(buf, opI32Store)
+		buf = append(buf, memarg(uint32(idx*4))...)
+	}
+
+	// closurePtr := alloc(8); tmp1 = closurePtr
+	buf = append(buf, opI32Const, 0x08)
+	buf = append(buf, opCall, allocFuncIdx)
+	buf = append(buf, opLocalSet, byte(outer.tmp1))
+
+	// closurePtr.tableIdx = tableIdx
+	buf = append(buf, opLocalGet, byte(outer.tmp1))
+	buf = append(buf, opI32Const)
+	buf = append(buf, sleb(int64(tableIdx))...)
+	buf = append(buf, opI32Store)
+	buf = append(buf, memarg(0)...)
+
+	// closurePtr.envPtr = envArrPtr
+	buf = append(buf, opLocalGet, byte(outer.tmp1))
+	buf = append(buf, opLocalGet, byte(outer.tmp0))
+	buf = append(buf, opI32Store)
+	buf = append(buf, memarg(4)...)
+
+	buf = append(buf, opLocalGet, byte(outer.tmp1))
+	return buf, nil
+}
+
+// assemble lays out the fixed runtime (alloc, apply, the bump-pointer
+// global, linear memory and the funcref table) around the Lambda bodies
+// collected in c.funcs, and returns the encoded module.
+func (c *compiler) assemble(mainBody []byte) []byte {
+	// Every vector below but codeEntry's own inner framing is counted by
+	// number of items (prependCount), not byte length; only section() and
+	// codeEntry() frame by byte length, per the module's binary format.
+	typeSec := prependCount(concat(
+		funcType([]byte{valTypeI32}, []byte{valTypeI32}),
+		funcType([]byte{valTypeI32, valTypeI32}, []byte{valTypeI32}),
+		funcType(nil, []byte{valTypeI32}),
+	), 3)
+
+	funcIndices := concat(uleb(typeAlloc), uleb(typeClosure), uleb(typeMain))
+	for range c.funcs {
+		funcIndices = append(funcIndices, uleb(typeClosure)...)
+	}
+	funcSec := prependCount(funcIndices, 3+len(c.funcs))
+
+	tableSec := prependCount(concat([]byte{0x70 /* funcref */, 0x00 /* flags: min only */}, uleb(uint32(len(c.funcs)))), 1)
+
+	memSec := prependCount([]byte{0x00 /* flags: min only */, 0x01 /* 1 page */}, 1)
+
+	globalSec := prependCount(concat(
+		[]byte{valTypeI32, 0x01}, // mutable i32
+		[]byte{opI32Const}, sleb(bumpInit), []byte{opEnd},
+	), 1)
+
+	exportSec := prependCount(concat(
+		exportEntry("memory", 0x02, 0),
+		exportEntry("main", 0x00, mainFuncIdx),
+	), 2)
+
+	elemIndices := make([]byte, 0, len(c.funcs))
+	for i := range c.funcs {
+		elemIndices = append(elemIndices, uleb(uint32(closureBaseFuncIdx+i))...)
+	}
+	elemSec := prependCount(concat(
+		[]byte{0x00}, // flag 0: active segment, table 0 implicit
+		[]byte{opI32Const}, sleb(0), []byte{opEnd},
+		prependCount(elemIndices, len(c.funcs)),
+	), 1)
+
+	codeEntries := concat(
+		codeEntry(0, allocBody()),
+		codeEntry(0, applyBody()),
+		// main has no parameters, so its tmp0/tmp1 registers (used by
+		// compileLambda when a Lambda is constructed at the top level)
+		// need their own declared locals rather than borrowing params.
+		codeEntry(2, mainBody),
+	)
+	for _, f := range c.funcs {
+		codeEntries = append(codeEntries, codeEntry(f.numLocals, f.body)...)
+	}
+	codeSec := prependCount(codeEntries, 3+len(c.funcs))
+
+	var out []byte
+	out = append(out, []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}...) // \0asm, version 1
+	out = append(out, section(1, typeSec)...)
+	out = append(out, section(3, funcSec)...)
+	out = append(out, section(4, tableSec)...)
+	out = append(out, section(5, memSec)...)
+	out = append(out, section(6, globalSec)...)
+	out = append(out, section(7, exportSec)...)
+	out = append(out, section(9, elemSec)...)
+	out = append(out, section(10, codeSec)...)
+	return out
+}
+
+// allocBody implements alloc(size i32) -> i32: bump the single global
+// pointer forward by size and return its previous value.
+func allocBody() []byte {
+	return []byte{
+		opGlobalGet, 0x00,
+		opGlobalGet, 0x00,
+		opLocalGet, 0x00,
+		opI32Add,
+		opGlobalSet, 0x00,
+		opEnd,
+	}
+}
+
+// applyBody implements apply(closurePtr, argPtr i32) -> i32: load the
+// callee's table index and captured env out of the closure record and
+// call_indirect into it with (envPtr, argPtr).
+func applyBody() []byte {
+	buf := []byte{opLocalGet, 0x00}
+	buf = append(buf, opI32Load)
+	buf = append(buf, memarg(4)...)     // envPtr
+	buf = append(buf, opLocalGet, 0x01) // argPtr
+	buf = append(buf, opLocalGet, 0x00)
+	buf = append(buf, opI32Load)
+	buf = append(buf, memarg(0)...) // tableIdx
+	buf = append(buf, opCallIndirect, typeClosure, 0x00 /* table 0 */)
+	buf = append(buf, opEnd)
+	return buf
+}
+
+func memarg(offset uint32) []byte {
+	return concat([]byte{0x02 /* align = 2^2, natural for i32 */}, uleb(offset))
+}
+
+func funcType(params, results []byte) []byte {
+	buf := []byte{funcTypeTag}
+	buf = append(buf, prependCount(params, len(params))...)
+	buf = append(buf, prependCount(results, len(results))...)
+	return buf
+}
+
+func exportEntry(name string, kind byte, idx int) []byte {
+	buf := prependCount([]byte(name), len(name))
+	buf = append(buf, kind)
+	buf = append(buf, uleb(uint32(idx))...)
+	return buf
+}
+
+func codeEntry(numI32Locals int, body []byte) []byte {
+	var localDecls []byte
+	if numI32Locals > 0 {
+		localDecls = concat(uleb(uint32(numI32Locals)), []byte{valTypeI32})
+		localDecls = prependCount(localDecls, 1)
+	} else {
+		localDecls = []byte{0x00}
+	}
+	payload := append(localDecls, body...)
+	return vec(payload)
+}
+
+func section(id byte, payload []byte) []byte {
+	return concat([]byte{id}, uleb(uint32(len(payload))), payload)
+}
+
+// vec prepends payload with its own byte length, the framing a code entry
+// (function body) uses; section() frames its payload the same way.
+func vec(payload []byte) []byte {
+	return concat(uleb(uint32(len(payload))), payload)
+}
+
+// prependCount prepends a ULEB128-encoded item count ahead of an
+// already-concatenated sequence of items (used where the count is a
+// number of logical items rather than a byte length).
+func prependCount(items []byte, count int) []byte {
+	return concat(uleb(uint32(count)), items)
+}
+
+func concat(parts ...[]byte) []byte {
+	var out []byte
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+func uleb(v uint32) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if v != 0 {
+			b |= 0x80
+		}
+		out = append(out, b)
+		if v == 0 {
+			return out
+		}
+	}
+}
+
+func sleb(v int64) []byte {
+	var out []byte
+	more := true
+	for more {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if (v == 0 && b&0x40 == 0) || (v == -1 && b&0x40 != 0) {
+			more = false
+		} else {
+			b |= 0x80
+		}
+		out = append(out, b)
+	}
+	return out
+}
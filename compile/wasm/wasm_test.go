@@ -0,0 +1,74 @@
+package wasm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+
+	"lambda/interp"
+)
+
+// parse treeifies script into a closed AST, the precondition Compile
+// requires (ast must have no free variables).
+func parse(t *testing.T, script string) interp.Element {
+	t.Helper()
+	src := interp.NewSource("t.txt", []byte(script))
+	tokens := interp.Tokenise(src)
+	ast, lerr := interp.Treeify(tokens, src)
+	if lerr != nil {
+		t.Fatalf("treeify: %v", lerr)
+	}
+	return ast
+}
+
+// TestCompileRunsUnderWazero compiles a closed expression to wasm and
+// runs its exported "main" via wazero, checking it executes without
+// trapping and returns a closure pointer whose table index resolves,
+// via the debug slice Compile also returns, to the same source text
+// interp.Print would show for the reduced result.
+func TestCompileRunsUnderWazero(t *testing.T) {
+	// (\x \y x) (\a a) (\b b): K applied to two identities, reducing to
+	// a closure over \a a.
+	ast := parse(t, `(\x \y x) (\a a) (\b b)`)
+
+	module, debug, err := Compile(ast)
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+
+	ctx := context.Background()
+	runtime := wazero.NewRuntime(ctx)
+	defer runtime.Close(ctx)
+
+	instance, err := runtime.Instantiate(ctx, module)
+	if err != nil {
+		t.Fatalf("Instantiate: %v", err)
+	}
+
+	results, err := instance.ExportedFunction("main").Call(ctx)
+	if err != nil {
+		t.Fatalf("call main: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("main returned %d results, want 1", len(results))
+	}
+
+	closurePtr := uint32(results[0])
+	mem := instance.ExportedMemory("memory")
+	if mem == nil {
+		t.Fatal("module does not export memory")
+	}
+
+	tableIdx, ok := mem.ReadUint32Le(closurePtr)
+	if !ok {
+		t.Fatalf("closure ref %d is not a valid memory address", closurePtr)
+	}
+	if tableIdx >= uint32(len(debug)) {
+		t.Fatalf("closure table index %d out of range", tableIdx)
+	}
+
+	if want := `\a a`; debug[tableIdx] != want {
+		t.Errorf("debug text for result closure = %q, want %q", debug[tableIdx], want)
+	}
+}
@@ -0,0 +1,751 @@
+// Package interp implements the lambda calculus lexer, parser, and both
+// evaluation backends (the original named-Scope interpreter and the
+// compiled De Bruijn one). It is the shared core imported by the CLI
+// frontends under cmd/ and by the compile/wasm backend.
+package interp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type ElementType int
+
+const (
+	LambdaElement ElementType = iota
+	ApplicationElement = iota
+	NameElement = iota
+	BracketElement = iota
+	BackslashElement = iota
+	ClosureElement = iota
+	ThunkElement = iota
+	InvalidElement = iota
+)
+
+type Element interface {
+	Type() ElementType
+}
+
+// ErrKind identifies which stage of the pipeline raised a LambdaError.
+type ErrKind int
+
+const (
+	LexError ErrKind = iota
+	ParseError
+	EvalError
+)
+
+func (k ErrKind) String() string {
+	switch k {
+	case LexError:
+		return "lex error"
+	case ParseError:
+		return "parse error"
+	case EvalError:
+		return "eval error"
+	}
+	return "error"
+}
+
+// Position is a human-readable source location, analogous to
+// token.Position in go/token.
+type Position struct {
+	File   string
+	Line   int
+	Column int
+	Offset int
+}
+
+func (p Position) String() string {
+	if p.File == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+// Span is the start/end position of a token or node within a Source.
+type Span struct {
+	Start Position
+	End   Position
+}
+
+// spanOf returns the Span of a node produced by treeifyExpression, so an
+// Application built from it can cover its full extent. Bracketed
+// sub-expressions fall through to Span{}'s zero value only when empty
+// (an expression that parsed to nil), which cannot occur for a non-nil
+// result or branch here.
+func spanOf(e Element) Span {
+	switch v := e.(type) {
+	case NameRef:
+		return v.Span
+	case Lambda:
+		return v.Span
+	case Application:
+		return v.Span
+	}
+	return Span{}
+}
+
+// LambdaError is a structured error carrying the position at which it was
+// raised, plus the stack of enclosing application sites (innermost first)
+// that were being evaluated when it occurred.
+type LambdaError struct {
+	Kind    ErrKind
+	Pos     Position
+	Message string
+	Stack   []Position
+}
+
+func (e *LambdaError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %s: %s", e.Pos, e.Kind, e.Message)
+	for _, p := range e.Stack {
+		fmt.Fprintf(&b, "\n\twhile applying %s", p)
+	}
+	return b.String()
+}
+
+// Source wraps a script's bytes together with a line-offset table so that
+// byte offsets produced by the lexer can be turned back into Positions,
+// the same way go/token.File associates offsets with a file.
+type Source struct {
+	File        string
+	Bytes       []byte
+	lineOffsets []int
+}
+
+func NewSource(file string, data []byte) *Source {
+	lineOffsets := []int{0}
+	for i, c := range data {
+		if c == '\n' {
+			lineOffsets = append(lineOffsets, i+1)
+		}
+	}
+	return &Source{File: file, Bytes: data, lineOffsets: lineOffsets}
+}
+
+func (s *Source) Position(offset int) Position {
+	line := sort.Search(len(s.lineOffsets), func(i int) bool {
+		return s.lineOffsets[i] > offset
+	}) - 1
+	if line < 0 {
+		line = 0
+	}
+	return Position{
+		File:   s.File,
+		Line:   line + 1,
+		Column: offset - s.lineOffsets[line] + 1,
+		Offset: offset,
+	}
+}
+
+type Name string
+
+func (_ Name) Type() ElementType {
+	return NameElement
+}
+
+// NameRef is a use of a Name within the tree; it carries the span the
+// identifier was written at, so that an unbound-variable error can be
+// reported with a location.
+type NameRef struct {
+	Name Name
+	Span Span
+}
+
+func (_ NameRef) Type() ElementType {
+	return NameElement
+}
+
+type Lambda struct {
+	Parameter  Name
+	Expression Element
+	Span       Span
+}
+
+func (_ Lambda) Type() ElementType {
+	return LambdaElement
+}
+
+type Scope struct {
+	Parent   *Scope
+	Variable Name
+	Value    Element
+}
+
+func (s Scope) Get(ref NameRef) (Element, *LambdaError) {
+	if s.Variable == ref.Name {
+		return s.Value, nil
+	}
+
+	if s.Parent == nil {
+		return nil, &LambdaError{
+			Kind:    EvalError,
+			Pos:     ref.Span.Start,
+			Message: fmt.Sprintf("variable not found: %q", ref.Name),
+		}
+	}
+
+	return (*s.Parent).Get(ref)
+}
+
+type Closure struct {
+	Enclosure  Scope
+	Parameter  Name
+	Expression Element
+	Span       Span
+}
+
+func (_ Closure) Type() ElementType {
+	return ClosureElement
+}
+
+// EvalStrategy selects how an Application binds its argument.
+type EvalStrategy int
+
+const (
+	// CallByValue evaluates an argument before binding it, the original
+	// behaviour of this interpreter.
+	CallByValue EvalStrategy = iota
+	// CallByNeed binds the argument as a Thunk that is evaluated at most
+	// once, the first time it is forced.
+	CallByNeed
+)
+
+// Thunk is an argument binding under CallByNeed: the unevaluated
+// expression plus the scope it closed over, memoising its normal form the
+// first time it is forced.
+type Thunk struct {
+	Expr     Element
+	Scope    Scope
+	Strategy EvalStrategy
+	Span     Span
+
+	forcing bool
+	done    bool
+	value   Element
+}
+
+func (_ *Thunk) Type() ElementType {
+	return ThunkElement
+}
+
+// force evaluates e if it is an unforced Thunk, memoising the result, and
+// otherwise returns e unchanged. It is called at every use site of a bound
+// variable: Name lookup and the function position of an Application.
+func force(e Element) (Element, *LambdaError) {
+	t, ok := e.(*Thunk)
+	if !ok {
+		return e, nil
+	}
+
+	if t.done {
+		return t.value, nil
+	}
+
+	if t.forcing {
+		return nil, &LambdaError{Kind: EvalError, Pos: t.Span.Start, Message: "thunk forced while already being forced (cyclic dependency)"}
+	}
+
+	t.forcing = true
+	value, err := Evaluate(t.Expr, t.Scope, t.Strategy)
+	t.forcing = false
+	if err != nil {
+		return nil, err
+	}
+
+	t.value = value
+	t.done = true
+	return value, nil
+}
+
+type Application struct {
+	A    Element
+	B    Element
+	Span Span
+}
+
+func (_ Application) Type() ElementType {
+	return ApplicationElement
+}
+
+type Bracket bool
+
+func (_ Bracket) Type() ElementType {
+	return BracketElement
+}
+
+type Backslash struct{}
+
+func (_ Backslash) Type() ElementType {
+	return BackslashElement
+}
+
+// Token pairs a lexical Element with the byte offsets it was read from.
+type Token struct {
+	Element Element
+	Start   int
+	End     int
+}
+
+func Tokenise(src *Source) []Token {
+	script := string(src.Bytes)
+
+	var tokens []Token
+
+	oldType := InvalidElement
+	newType := InvalidElement
+
+	var currentToken strings.Builder
+	tokenStart := 0
+
+	for i := 0; i <= len(script); i++ {
+		var c byte
+
+		if i == len(script) {
+			c = '\n'
+		} else {
+			c = script[i]
+		}
+
+		switch c {
+		case ' ', '\n', '\r', '\t':
+			newType = InvalidElement
+		case '(', ')':
+			newType = BracketElement
+		case '\\':
+			newType = BackslashElement
+		default:
+			newType = NameElement
+		}
+
+		// Bracket and Backslash are always single-character tokens, so
+		// two of them in a row (e.g. the "))" that closes two nested
+		// applications) must still be split, even though they share a
+		// type and would otherwise look like one run to NameElement's
+		// multi-character accumulation below.
+		singleChar := newType == BracketElement || newType == BackslashElement
+
+		if (oldType != newType || singleChar) && currentToken.Len() > 0 {
+			start := tokenStart
+			switch oldType {
+			case BackslashElement:
+				tokens = append(tokens, Token{Backslash{}, start, i})
+			case BracketElement:
+				tokens = append(tokens, Token{Bracket(currentToken.String()[0] == '('), start, i})
+			case NameElement:
+				tokens = append(tokens, Token{Name(currentToken.String()), start, i})
+			}
+
+			currentToken.Reset()
+		}
+
+		if oldType != newType || singleChar {
+			tokenStart = i
+		}
+
+		oldType = newType
+
+		if oldType != InvalidElement {
+			currentToken.WriteByte(c)
+		}
+	}
+
+	return tokens
+}
+
+func Treeify(tokens []Token, src *Source) (Element, *LambdaError) {
+	var i int
+	return treeifyExpression(&i, tokens, src)
+}
+
+func treeifyExpression(i *int, tokens []Token, src *Source) (Element, *LambdaError) {
+	var result Element
+
+	var branch Element
+
+	for ; *i < len(tokens); *i++ {
+		t := tokens[*i]
+
+		switch v := t.Element.(type) {
+		case Bracket:
+			if !v {
+				return result, nil
+			}
+
+			*i++
+			b, err := treeifyExpression(i, tokens, src)
+			if err != nil {
+				return nil, err
+			}
+			branch = b
+		case Backslash:
+			start := t.Start
+			*i++
+			if *i >= len(tokens) {
+				return nil, &LambdaError{Kind: ParseError, Pos: src.Position(start), Message: "expected parameter name after '\\'"}
+			}
+			parameter, ok := tokens[*i].Element.(Name)
+			if !ok {
+				return nil, &LambdaError{Kind: ParseError, Pos: src.Position(tokens[*i].Start), Message: "expected parameter name after '\\'"}
+			}
+			*i++
+			body, err := treeifyExpression(i, tokens, src)
+			if err != nil {
+				return nil, err
+			}
+			end := start
+			if *i < len(tokens) {
+				end = tokens[*i].End
+			} else if len(tokens) > 0 {
+				end = tokens[len(tokens)-1].End
+			}
+			branch = Lambda{parameter, body, Span{src.Position(start), src.Position(end)}}
+			*i--
+		case Name:
+			branch = NameRef{v, Span{src.Position(t.Start), src.Position(t.End)}}
+		default:
+			branch = t.Element
+		}
+
+		if result == nil {
+			result = branch
+		} else {
+			result = Application{result, branch, Span{spanOf(result).Start, spanOf(branch).End}}
+		}
+	}
+
+	return result, nil
+}
+
+func Evaluate(e Element, scope Scope, strategy EvalStrategy) (Element, *LambdaError) {
+	switch v := e.(type) {
+	case NameRef:
+		raw, err := scope.Get(v)
+		if err != nil {
+			return nil, err
+		}
+		return force(raw)
+	case Lambda:
+		return Closure{scope, v.Parameter, v.Expression, v.Span}, nil
+	case Application:
+		rawA, err := Evaluate(v.A, scope, strategy)
+		if err != nil {
+			err.Stack = append(err.Stack, v.Span.Start)
+			return nil, err
+		}
+
+		a, err := force(rawA)
+		if err != nil {
+			err.Stack = append(err.Stack, v.Span.Start)
+			return nil, err
+		}
+
+		closure, ok := a.(Closure)
+		if !ok {
+			return nil, &LambdaError{Kind: EvalError, Pos: v.Span.Start, Message: "left-hand side of application is not a closure"}
+		}
+
+		var bound Element
+		if strategy == CallByNeed {
+			bound = &Thunk{Expr: v.B, Scope: scope, Strategy: strategy, Span: v.Span}
+		} else {
+			b, err := Evaluate(v.B, scope, strategy)
+			if err != nil {
+				err.Stack = append(err.Stack, v.Span.Start)
+				return nil, err
+			}
+			bound = b
+		}
+
+		newScope := Scope{&closure.Enclosure, closure.Parameter, bound}
+		result, err := Evaluate(closure.Expression, newScope, strategy)
+		if err != nil {
+			err.Stack = append(err.Stack, v.Span.Start)
+			return nil, err
+		}
+		return result, nil
+	}
+
+	return nil, nil
+}
+
+// IxVar is a nameless variable reference: Index counts binders outward
+// from the innermost enclosing Lambda, i.e. a De Bruijn index.
+type IxVar struct {
+	Index int
+	Span  Span
+}
+
+func (_ IxVar) Type() ElementType {
+	return NameElement
+}
+
+// IxLam is a Lambda with its parameter erased; the parameter is implicit
+// at De Bruijn index 0 within Body.
+type IxLam struct {
+	Body Element
+	Span Span
+}
+
+func (_ IxLam) Type() ElementType {
+	return LambdaElement
+}
+
+// IxApp is an Application over compiled elements.
+type IxApp struct {
+	A, B Element
+	Span Span
+}
+
+func (_ IxApp) Type() ElementType {
+	return ApplicationElement
+}
+
+// IxClosure is the runtime value an IxLam evaluates to: the body plus the
+// slice-backed environment it closed over, innermost binding first.
+type IxClosure struct {
+	Env  []Element
+	Body Element
+	Span Span
+}
+
+func (_ IxClosure) Type() ElementType {
+	return ClosureElement
+}
+
+// IxThunk is the De Bruijn counterpart of Thunk, binding an environment
+// slice instead of a linked Scope.
+type IxThunk struct {
+	Expr     Element
+	Env      []Element
+	Strategy EvalStrategy
+	Span     Span
+
+	forcing bool
+	done    bool
+	value   Element
+}
+
+func (_ *IxThunk) Type() ElementType {
+	return ThunkElement
+}
+
+func forceIx(e Element) (Element, *LambdaError) {
+	t, ok := e.(*IxThunk)
+	if !ok {
+		return e, nil
+	}
+
+	if t.done {
+		return t.value, nil
+	}
+
+	if t.forcing {
+		return nil, &LambdaError{Kind: EvalError, Pos: t.Span.Start, Message: "thunk forced while already being forced (cyclic dependency)"}
+	}
+
+	t.forcing = true
+	value, err := EvaluateIx(t.Expr, t.Env, t.Strategy)
+	t.forcing = false
+	if err != nil {
+		return nil, err
+	}
+
+	t.value = value
+	t.done = true
+	return value, nil
+}
+
+// Compile lowers a named AST produced by Treeify into the nameless De
+// Bruijn representation, resolving each NameRef against env, the names
+// currently in scope with the innermost binder first.
+func Compile(e Element, env []Name) (Element, *LambdaError) {
+	switch v := e.(type) {
+	case NameRef:
+		for idx, n := range env {
+			if n == v.Name {
+				return IxVar{Index: idx, Span: v.Span}, nil
+			}
+		}
+		return nil, &LambdaError{Kind: ParseError, Pos: v.Span.Start, Message: fmt.Sprintf("unbound variable: %q", v.Name)}
+	case Lambda:
+		body, err := Compile(v.Expression, append([]Name{v.Parameter}, env...))
+		if err != nil {
+			return nil, err
+		}
+		return IxLam{Body: body, Span: v.Span}, nil
+	case Application:
+		a, err := Compile(v.A, env)
+		if err != nil {
+			return nil, err
+		}
+		b, err := Compile(v.B, env)
+		if err != nil {
+			return nil, err
+		}
+		return IxApp{A: a, B: b, Span: v.Span}, nil
+	}
+
+	return e, nil
+}
+
+// EvaluateIx runs the compiled, nameless form of Evaluate: env is a
+// slice-backed environment (innermost binding at index 0) instead of a
+// linked Scope, so variable lookup is O(1) and Application no longer
+// allocates a Scope node per call.
+func EvaluateIx(e Element, env []Element, strategy EvalStrategy) (Element, *LambdaError) {
+	switch v := e.(type) {
+	case IxVar:
+		if v.Index >= len(env) {
+			return nil, &LambdaError{Kind: EvalError, Pos: v.Span.Start, Message: "variable index out of range"}
+		}
+		return forceIx(env[v.Index])
+	case IxLam:
+		return IxClosure{Env: env, Body: v.Body, Span: v.Span}, nil
+	case IxApp:
+		rawA, err := EvaluateIx(v.A, env, strategy)
+		if err != nil {
+			err.Stack = append(err.Stack, v.Span.Start)
+			return nil, err
+		}
+
+		a, err := forceIx(rawA)
+		if err != nil {
+			err.Stack = append(err.Stack, v.Span.Start)
+			return nil, err
+		}
+
+		closure, ok := a.(IxClosure)
+		if !ok {
+			return nil, &LambdaError{Kind: EvalError, Pos: v.Span.Start, Message: "left-hand side of application is not a closure"}
+		}
+
+		var bound Element
+		if strategy == CallByNeed {
+			bound = &IxThunk{Expr: v.B, Env: env, Strategy: strategy, Span: v.Span}
+		} else {
+			b, err := EvaluateIx(v.B, env, strategy)
+			if err != nil {
+				err.Stack = append(err.Stack, v.Span.Start)
+				return nil, err
+			}
+			bound = b
+		}
+
+		newEnv := append([]Element{bound}, closure.Env...)
+		result, err := EvaluateIx(closure.Body, newEnv, strategy)
+		if err != nil {
+			err.Stack = append(err.Stack, v.Span.Start)
+			return nil, err
+		}
+		return result, nil
+	}
+
+	return nil, nil
+}
+
+// Decompile re-materializes a readable named tree from the nameless
+// compiled form, synthesizing a parameter name per binder depth, so debug
+// output can show e.g. "\x0 x0" rather than "\.0". An IxClosure is
+// decompiled as its bare body; its Env is not substituted, so a variable
+// bound in Env gets a negative-numbered name such as "x-1".
+func Decompile(e Element, depth int) Element {
+	switch v := e.(type) {
+	case IxVar:
+		return NameRef{Name: Name(fmt.Sprintf("x%d", depth-1-v.Index)), Span: v.Span}
+	case IxLam:
+		return Lambda{
+			Parameter:  Name(fmt.Sprintf("x%d", depth)),
+			Expression: Decompile(v.Body, depth+1),
+			Span:       v.Span,
+		}
+	case IxApp:
+		return Application{A: Decompile(v.A, depth), B: Decompile(v.B, depth), Span: v.Span}
+	case IxClosure:
+		return Decompile(IxLam{Body: v.Body, Span: v.Span}, depth)
+	}
+
+	return e
+}
+
+// FreeVars returns the names referenced in e that are not bound by an
+// enclosing Lambda within e itself, in first-occurrence order. It operates
+// on the named tree produced by Treeify, before any De Bruijn compilation.
+func FreeVars(e Element) []Name {
+	seen := map[Name]bool{}
+	var order []Name
+
+	var walk func(e Element, bound map[Name]bool)
+	walk = func(e Element, bound map[Name]bool) {
+		switch v := e.(type) {
+		case NameRef:
+			if bound[v.Name] || seen[v.Name] {
+				return
+			}
+			seen[v.Name] = true
+			order = append(order, v.Name)
+		case Lambda:
+			inner := make(map[Name]bool, len(bound)+1)
+			for n := range bound {
+				inner[n] = true
+			}
+			inner[v.Parameter] = true
+			walk(v.Expression, inner)
+		case Application:
+			walk(v.A, bound)
+			walk(v.B, bound)
+		}
+	}
+
+	walk(e, map[Name]bool{})
+	return order
+}
+
+// Print renders e as lambda-calculus source text, the form a user would
+// type back in. It understands both the named tree produced by
+// Treeify/Evaluate and the nameless tree produced by Compile/EvaluateIx,
+// decompiling the latter to names first.
+func Print(e Element) string {
+	switch e.(type) {
+	case IxVar, IxLam, IxApp, IxClosure:
+		e = Decompile(e, 0)
+	}
+	return printAt(e, false)
+}
+
+// printAt renders e, parenthesizing it if parens is set and e is a form
+// that would otherwise be ambiguous when juxtaposed: a Lambda's body
+// extends as far right as possible, so both a Lambda applied to something
+// and a Lambda or Application used as an argument need parens to round-trip.
+func printAt(e Element, parens bool) string {
+	switch v := e.(type) {
+	case NameRef:
+		return string(v.Name)
+	case Name:
+		return string(v)
+	case Lambda:
+		return wrap(`\`+string(v.Parameter)+" "+printAt(v.Expression, false), parens)
+	case Closure:
+		return wrap(`\`+string(v.Parameter)+" "+printAt(v.Expression, false), parens)
+	case Application:
+		return wrap(printAt(v.A, isAbstraction(v.A))+" "+printAt(v.B, true), parens)
+	}
+	return fmt.Sprintf("%#v", e)
+}
+
+func isAbstraction(e Element) bool {
+	switch e.(type) {
+	case Lambda, Closure:
+		return true
+	}
+	return false
+}
+
+func wrap(s string, parens bool) string {
+	if parens {
+		return "(" + s + ")"
+	}
+	return s
+}
@@ -0,0 +1,170 @@
+package interp
+
+import "testing"
+
+// evalSrc parses and evaluates script under strategy, starting from an
+// empty top-level Scope.
+func evalSrc(t *testing.T, script string, strategy EvalStrategy) (Element, *LambdaError) {
+	t.Helper()
+	src := NewSource("t.txt", []byte(script))
+	tokens := Tokenise(src)
+	ast, lerr := Treeify(tokens, src)
+	if lerr != nil {
+		return nil, lerr
+	}
+	return Evaluate(ast, Scope{}, strategy)
+}
+
+// TestCallByNeedTolerateNonterminatingArgument checks that, under
+// CallByNeed, an argument that would diverge under CallByValue is
+// accepted as long as it is never forced.
+func TestCallByNeedTolerateNonterminatingArgument(t *testing.T) {
+	// omega = (\x x x) (\x x x) diverges if forced; K discards its
+	// second argument, so (\x \y x) a omega must still reduce to a.
+	_, lerr := evalSrc(t, `(\x \y x) (\z z) ((\x x x) (\x x x))`, CallByNeed)
+	if lerr != nil {
+		t.Fatalf("evaluate: %v", lerr)
+	}
+}
+
+// TestEvaluateIxCallByNeedToleratesNonterminatingArgument is the
+// compiled-path counterpart of TestCallByNeedTolerateNonterminatingArgument:
+// Compile + EvaluateIx under CallByNeed is exactly what `lambda -lazy`
+// (the default, compiled frontend) runs, so it needs the same coverage
+// as the named path.
+func TestEvaluateIxCallByNeedToleratesNonterminatingArgument(t *testing.T) {
+	ast := parse(t, `(\x \y x) (\z z) ((\x x x) (\x x x))`)
+
+	compiled, lerr := Compile(ast, nil)
+	if lerr != nil {
+		t.Fatalf("compile: %v", lerr)
+	}
+
+	_, lerr = EvaluateIx(compiled, nil, CallByNeed)
+	if lerr != nil {
+		t.Fatalf("evaluateIx: %v", lerr)
+	}
+}
+
+// TestForceCycleDetection builds a Thunk that resolves to itself (the
+// Scope it forces under binds its own variable back to it) and checks
+// that force reports a LambdaError instead of recursing forever.
+func TestForceCycleDetection(t *testing.T) {
+	ref := NameRef{Name: "x"}
+	thunk := &Thunk{Expr: ref}
+	thunk.Scope = Scope{Variable: "x", Value: thunk}
+
+	_, lerr := force(thunk)
+	if lerr == nil {
+		t.Fatal("expected a cyclic-dependency error, got none")
+	}
+	if lerr.Kind != EvalError {
+		t.Fatalf("unexpected error kind: %v", lerr)
+	}
+}
+
+// TestForceIxCycleDetection is the De Bruijn counterpart of
+// TestForceCycleDetection, using an environment slice instead of a Scope.
+func TestForceIxCycleDetection(t *testing.T) {
+	thunk := &IxThunk{Expr: IxVar{Index: 0}}
+	thunk.Env = []Element{thunk}
+
+	_, lerr := forceIx(thunk)
+	if lerr == nil {
+		t.Fatal("expected a cyclic-dependency error, got none")
+	}
+	if lerr.Kind != EvalError {
+		t.Fatalf("unexpected error kind: %v", lerr)
+	}
+}
+
+// parse is the Compile-path counterpart of evalSrc: Tokenise/Treeify
+// script and return the named AST, without evaluating it.
+func parse(t *testing.T, script string) Element {
+	t.Helper()
+	src := NewSource("t.txt", []byte(script))
+	tokens := Tokenise(src)
+	ast, lerr := Treeify(tokens, src)
+	if lerr != nil {
+		t.Fatalf("treeify: %v", lerr)
+	}
+	return ast
+}
+
+// TestCompileDecompileRoundTrip checks that compiling a closed named tree
+// to De Bruijn indices and decompiling it back reproduces the same
+// binding structure, under Decompile's synthesized "xN" names (one per
+// binder depth) rather than the original parameter names.
+func TestCompileDecompileRoundTrip(t *testing.T) {
+	cases := []struct {
+		script string
+		want   string
+	}{
+		{`\x x`, `\x0 x0`},
+		{`\x \y x`, `\x0 \x1 x0`},
+		{`\f (\x f (x x)) (\x f (x x))`, `\x0 (\x1 x0 (x1 x1)) (\x1 x0 (x1 x1))`},
+	}
+
+	for _, c := range cases {
+		ast := parse(t, c.script)
+		compiled, lerr := Compile(ast, nil)
+		if lerr != nil {
+			t.Fatalf("compile %q: %v", c.script, lerr)
+		}
+		if got := Print(compiled); got != c.want {
+			t.Errorf("decompile %q: got %q, want %q", c.script, got, c.want)
+		}
+	}
+}
+
+// TestCompileUnboundVariable checks that Compile reports an unbound
+// variable by name and position instead of a nil-index panic.
+func TestCompileUnboundVariable(t *testing.T) {
+	ast := parse(t, `\x y`)
+	_, lerr := Compile(ast, nil)
+	if lerr == nil {
+		t.Fatal("expected an unbound-variable error, got none")
+	}
+	if lerr.Kind != ParseError {
+		t.Fatalf("unexpected error kind: %v", lerr)
+	}
+	if want := `unbound variable: "y"`; lerr.Message != want {
+		t.Errorf("message = %q, want %q", lerr.Message, want)
+	}
+	if lerr.Pos.Line != 1 || lerr.Pos.Column != 4 {
+		t.Errorf("position = %v, want 1:4", lerr.Pos)
+	}
+}
+
+// TestEvaluateIxMatchesEvaluate checks that the compiled, slice-backed
+// evaluator and the original named-Scope evaluator agree on a result, up
+// to the parameter renaming Decompile applies: the named result's source
+// text is re-parsed and re-compiled so both sides go through Decompile's
+// same "xN" naming before comparison.
+func TestEvaluateIxMatchesEvaluate(t *testing.T) {
+	script := `(\x \y x) (\z z) (\z \w z)`
+
+	ast := parse(t, script)
+	named, lerr := Evaluate(ast, Scope{}, CallByValue)
+	if lerr != nil {
+		t.Fatalf("evaluate: %v", lerr)
+	}
+
+	compiled, lerr := Compile(ast, nil)
+	if lerr != nil {
+		t.Fatalf("compile: %v", lerr)
+	}
+	ixResult, lerr := EvaluateIx(compiled, nil, CallByValue)
+	if lerr != nil {
+		t.Fatalf("evaluateIx: %v", lerr)
+	}
+
+	namedCompiled, lerr := Compile(parse(t, Print(named)), nil)
+	if lerr != nil {
+		t.Fatalf("compile named result: %v", lerr)
+	}
+
+	if got, want := Print(ixResult), Print(namedCompiled); got != want {
+		t.Errorf("EvaluateIx result %q, want %q (matching Evaluate)", got, want)
+	}
+}
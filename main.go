@@ -1,202 +1,93 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"strings"
-	"os"
 	"io"
-)
-
-type ElementType int
+	"os"
 
-const (
-	LambdaElement ElementType = iota
-	ApplicationElement = iota
-	NameElement = iota
-	BracketElement = iota
-	BackslashElement = iota
-	ClosureElement = iota
-	InvalidElement = iota
+	"lambda/compile/wasm"
+	"lambda/interp"
 )
 
-type Element interface {
-	Type() ElementType
-}
-
-type Name string
-
-func (_ Name) Type() ElementType {
-	return NameElement
-}
-
-type Lambda struct {
-	Parameter Name
-	Expression Element
-}
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "build":
+			runBuild(os.Args[2:])
+			return
+		case "repl":
+			runRepl(os.Args[2:])
+			return
+		}
+	}
 
-func (_ Lambda) Type() ElementType {
-	return LambdaElement
+	runDefault()
 }
 
-type Scope struct {
-	Parent *Scope
-	Variable Name
-	Value Element
-}
+// runBuild implements `lambda build -o out.wasm script.txt`: it compiles
+// a script to a standalone WebAssembly module via compile/wasm, writing
+// the module to -o and its debug text to -o+".debug".
+func runBuild(args []string) {
+	fs := flag.NewFlagSet("build", flag.ExitOnError)
+	out := fs.String("o", "out.wasm", "output path for the compiled module")
+	fs.Parse(args)
 
-func (s Scope) Get(n Name) Element {
-	if s.Variable == n {
-		return s.Value
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: lambda build -o out.wasm script.txt")
+		os.Exit(1)
 	}
+	scriptPath := fs.Arg(0)
 
-	if s.Parent == nil {
-		panic(fmt.Sprintf("variable not found: %q", n))
+	script, e := os.ReadFile(scriptPath)
+	if e != nil {
+		fmt.Fprintf(os.Stderr, "can't read %s: %v\n", scriptPath, e)
+		os.Exit(1)
 	}
 
-	return (*s.Parent).Get(n)
-}
-
-type Closure struct {
-	Enclosure Scope
-	Parameter Name
-	Expression Element
-}
-
-func (_ Closure) Type() ElementType {
-	return ClosureElement
-}
-
-type Application struct {
-	A Element
-	B Element
-}
-
-func (_ Application) Type() ElementType {
-	return ApplicationElement
-}
-
-type Bracket bool
-
-func (_ Bracket) Type() ElementType {
-	return BracketElement
-}
-
-type Backslash struct{}
-
-func (_ Backslash) Type() ElementType {
-	return BackslashElement
-}
-
-func tokenise(script string) []Element {
-	var tokens []Element
+	src := interp.NewSource(scriptPath, script)
+	tokens := interp.Tokenise(src)
 
-	oldType := InvalidElement
-	newType := InvalidElement
-
-	var currentToken strings.Builder
-
-	for i := 0; i <= len(script); i++ {
-		var c byte
-
-		if i == len(script) {
-			c = '\n'
-		} else {
-			c = script[i]
-		}
-
-		switch c {
-		case ' ', '\n', '\r', '\t':
-			newType = InvalidElement
-		case '(', ')':
-			newType = BracketElement
-		case '\\':
-			newType = BackslashElement
-		default:
-			newType = NameElement
-		}
-
-		if oldType != newType && currentToken.Len() > 0 {
-			switch oldType {
-			case BackslashElement:
-				tokens = append(tokens, Backslash{})
-			case BracketElement:
-				tokens = append(tokens, Bracket(currentToken.String()[0] == '('))
-			case NameElement:
-				tokens = append(tokens, Name(currentToken.String()))
-			}
-
-			currentToken.Reset()
-		}
-
-		oldType = newType
-
-		if oldType != InvalidElement {
-			currentToken.WriteByte(c)
-		}
+	ast, lerr := interp.Treeify(tokens, src)
+	if lerr != nil {
+		fmt.Fprintln(os.Stderr, lerr)
+		os.Exit(1)
 	}
 
-	return tokens
-}
-
-func treeify(tokens []Element) Element {
-	var i int
-	return treeifyExpression(&i, tokens)
-}
+	module, debug, err := wasm.Compile(ast)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
-func treeifyExpression(i *int, tokens []Element) Element {
-	var result Element
-
-	var branch Element
-
-	for ; *i < len(tokens); *i++ {
-		e := tokens[*i]
-
-		switch v := e.(type) {
-		case Bracket:
-			if !v {
-				return result
-			}
-
-			*i++
-			branch = treeifyExpression(i, tokens)
-		case Backslash:
-			*i++
-			parameter := tokens[*i].(Name)
-			*i++
-			branch = Lambda{parameter, treeifyExpression(i, tokens)}
-			*i--
-		default:
-			branch = e
-		}
+	if err := os.WriteFile(*out, module, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "can't write %s: %v\n", *out, err)
+		os.Exit(1)
+	}
 
-		if result == nil {
-			result = branch
-		} else {
-			result = Application{result, branch}
-		}
+	debugPath := *out + wasm.DebugExt
+	if err := os.WriteFile(debugPath, wasm.EncodeDebug(debug), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "can't write %s: %v\n", debugPath, err)
+		os.Exit(1)
 	}
 
-	return result
+	fmt.Printf("wrote %s and %s\n", *out, debugPath)
 }
 
-func evaluate(e Element, scope Scope) Element {
-	switch v := e.(type) {
-	case Name:
-		return scope.Get(v)
-	case Lambda:
-		return Closure{scope, v.Parameter, v.Expression}
-	case Application:
-		a := evaluate(v.A, scope).(Closure)
-		b := evaluate(v.B, scope)
-
-		newScope := Scope{&a.Enclosure, a.Parameter, b}
-		return evaluate(a.Expression, newScope)
-	}
+// runDefault is the original behaviour: tree-walk script.txt, printing
+// each stage. It normally compiles to De Bruijn indices before
+// evaluating; -named keeps the linked-Scope interpreter for debugging.
+func runDefault() {
+	fs := flag.NewFlagSet("", flag.ExitOnError)
+	named := fs.Bool("named", false, "evaluate the named AST directly instead of compiling to De Bruijn indices")
+	lazy := fs.Bool("lazy", false, "evaluate under CallByNeed instead of the default CallByValue")
+	fs.Parse(os.Args[1:])
 
-	return nil
-}
+	strategy := interp.CallByValue
+	if *lazy {
+		strategy = interp.CallByNeed
+	}
 
-func main() {
 	file, e := os.Open("script.txt")
 	if e != nil {
 		panic("can't open script file")
@@ -209,12 +100,39 @@ func main() {
 
 	file.Close()
 
-	tokens := tokenise(string(script))
+	src := interp.NewSource("script.txt", script)
+
+	tokens := interp.Tokenise(src)
 	fmt.Printf("tokens: %#v\n\n", tokens)
 
-	ast := treeify(tokens)
+	ast, lerr := interp.Treeify(tokens, src)
+	if lerr != nil {
+		fmt.Fprintln(os.Stderr, lerr)
+		os.Exit(1)
+	}
 	fmt.Printf("ast: %#v\n\n", ast)
 
-	result := evaluate(ast, Scope{})
-	fmt.Printf("result: %#v\n\n", result)
+	if *named {
+		result, lerr := interp.Evaluate(ast, interp.Scope{}, strategy)
+		if lerr != nil {
+			fmt.Fprintln(os.Stderr, lerr)
+			os.Exit(1)
+		}
+		fmt.Printf("result: %#v\n\n", result)
+		return
+	}
+
+	compiled, lerr := interp.Compile(ast, nil)
+	if lerr != nil {
+		fmt.Fprintln(os.Stderr, lerr)
+		os.Exit(1)
+	}
+	fmt.Printf("compiled: %#v\n\n", compiled)
+
+	result, lerr := interp.EvaluateIx(compiled, nil, strategy)
+	if lerr != nil {
+		fmt.Fprintln(os.Stderr, lerr)
+		os.Exit(1)
+	}
+	fmt.Printf("result: %#v\n\n", interp.Decompile(result, 0))
 }
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestPreludeYIsSelfApplication is a regression check for a lexer bug
+// where two adjacent brackets of the same kind (the "))" closing the
+// prelude's Y definition) coalesced into a single token, silently
+// mis-nesting Y into one lambda instead of an application of two. It
+// evaluates the real, embedded prelude.lambda and checks the bound Y
+// prints back as the self-application its source text spells out,
+// without reducing it (Y applied to nothing can't diverge). The REPL's
+// default pipeline compiles to De Bruijn indices, so the printed form
+// uses Decompile's synthesized "xN" names rather than f/x.
+func TestPreludeYIsSelfApplication(t *testing.T) {
+	st := &replState{}
+	st.loadPrelude()
+
+	var out bytes.Buffer
+	st.handleLine("Y", &out)
+
+	want := "\\x0 (\\x1 x0 (x1 x1)) (\\x1 x0 (x1 x1))\n"
+	if got := out.String(); got != want {
+		t.Errorf("Y = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bufio"
+	_ "embed"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"lambda/interp"
+)
+
+//go:embed prelude.lambda
+var preludeSource string
+
+// replState is the mutable environment of a REPL session: the persistent
+// top-level bindings built up by the prelude and by :let, plus a counter
+// used to give each parsed line its own Source name for error reporting.
+//
+// Bindings are kept in one of two representations depending on named:
+// scope, the original linked Scope, when -named was given; or names and
+// values, a De Bruijn environment (innermost binding first, matching the
+// slice Compile/EvaluateIx expect) by default. This mirrors runDefault's
+// choice between the two evaluators, so the REPL gets the same O(1)
+// variable lookup, with no Scope node allocated per Application, that the
+// default frontend uses.
+type replState struct {
+	scope  interp.Scope
+	names  []interp.Name
+	values []interp.Element
+
+	line      int
+	noPrelude bool
+	strategy  interp.EvalStrategy
+	named     bool
+}
+
+// runRepl implements `lambda repl`: an interactive, line-at-a-time
+// front end over the same Tokenise/Treeify pipeline the other subcommands
+// use, plus a handful of meta-commands for managing bindings. Like
+// runDefault, it evaluates over compiled De Bruijn indices unless -named
+// asks for the linked-Scope interpreter instead.
+func runRepl(args []string) {
+	fs := flag.NewFlagSet("repl", flag.ExitOnError)
+	noPrelude := fs.Bool("no-prelude", false, "don't load the standard prelude on startup")
+	lazy := fs.Bool("lazy", false, "evaluate under CallByNeed instead of the default CallByValue")
+	named := fs.Bool("named", false, "evaluate the named AST directly instead of compiling to De Bruijn indices")
+	fs.Parse(args)
+
+	strategy := interp.CallByValue
+	if *lazy {
+		strategy = interp.CallByNeed
+	}
+
+	st := &replState{noPrelude: *noPrelude, strategy: strategy, named: *named}
+	st.loadPrelude()
+
+	fmt.Println("lambda repl - :let name = expr, :load path, :type/:ast expr, :strategy [value|need], :reset, :quit")
+	st.loop(os.Stdin, os.Stdout)
+}
+
+func (st *replState) loadPrelude() {
+	if st.noPrelude {
+		return
+	}
+	st.loadSource("prelude", preludeSource, io.Discard)
+}
+
+// loop reads lines from in, accumulating them until parentheses balance
+// (supporting expressions that span more than one line), then hands each
+// complete chunk to handleLine.
+func (st *replState) loop(in io.Reader, out io.Writer) {
+	scanner := bufio.NewScanner(in)
+	fmt.Fprint(out, "> ")
+
+	var pending strings.Builder
+	depth := 0
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		pending.WriteString(line)
+		pending.WriteByte('\n')
+		depth += bracketDepth(line)
+
+		if depth > 0 {
+			fmt.Fprint(out, "... ")
+			continue
+		}
+
+		st.handleLine(strings.TrimSpace(pending.String()), out)
+		pending.Reset()
+		depth = 0
+		fmt.Fprint(out, "> ")
+	}
+}
+
+func bracketDepth(line string) int {
+	depth := 0
+	for _, c := range line {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		}
+	}
+	return depth
+}
+
+// loadSource splices script in line by line as if it had been typed at
+// the prompt, writing any evaluated results to out. It's shared by :load
+// and by the prelude, which loads silently (out is io.Discard).
+func (st *replState) loadSource(name, script string, out io.Writer) {
+	var pending strings.Builder
+	depth := 0
+
+	for _, line := range strings.Split(script, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		pending.WriteString(line)
+		pending.WriteByte('\n')
+		depth += bracketDepth(line)
+		if depth > 0 {
+			continue
+		}
+		if chunk := strings.TrimSpace(pending.String()); chunk != "" {
+			st.handleLine(chunk, out)
+		}
+		pending.Reset()
+		depth = 0
+	}
+}
+
+// handleLine dispatches a complete, balanced chunk of input: a meta-command
+// or a plain expression to evaluate under the current scope.
+func (st *replState) handleLine(line string, out io.Writer) {
+	switch {
+	case line == "":
+		return
+	case line == ":reset":
+		st.scope = interp.Scope{}
+		st.names = nil
+		st.values = nil
+		st.loadPrelude()
+		return
+	case line == ":quit" || line == ":q":
+		os.Exit(0)
+	case strings.HasPrefix(line, ":let "):
+		st.handleLet(strings.TrimPrefix(line, ":let "), out)
+		return
+	case strings.HasPrefix(line, ":load "):
+		st.handleLoad(strings.TrimSpace(strings.TrimPrefix(line, ":load ")), out)
+		return
+	case strings.HasPrefix(line, ":type "):
+		st.handleShow(strings.TrimPrefix(line, ":type "), out)
+		return
+	case strings.HasPrefix(line, ":ast "):
+		st.handleShow(strings.TrimPrefix(line, ":ast "), out)
+		return
+	case line == ":strategy" || strings.HasPrefix(line, ":strategy "):
+		st.handleStrategy(strings.TrimSpace(strings.TrimPrefix(line, ":strategy")), out)
+		return
+	}
+
+	ast, lerr := st.parse(line)
+	if lerr != nil {
+		fmt.Fprintln(os.Stderr, lerr)
+		return
+	}
+
+	result, lerr := st.eval(ast)
+	if lerr != nil {
+		fmt.Fprintln(os.Stderr, lerr)
+		return
+	}
+	fmt.Fprintln(out, interp.Print(result))
+}
+
+// eval runs ast through whichever pipeline this session uses: the linked
+// Scope interpreter under -named, or the default Compile+EvaluateIx pair
+// runDefault normally runs. interp.Print already decompiles an
+// EvaluateIx result back to named form, so callers don't need to.
+func (st *replState) eval(ast interp.Element) (interp.Element, *interp.LambdaError) {
+	if st.named {
+		return interp.Evaluate(ast, st.scope, st.strategy)
+	}
+
+	compiled, lerr := interp.Compile(ast, st.names)
+	if lerr != nil {
+		return nil, lerr
+	}
+	return interp.EvaluateIx(compiled, st.values, st.strategy)
+}
+
+// handleStrategy implements `:strategy` (report the current EvalStrategy)
+// and `:strategy value`/`:strategy need` (switch it).
+func (st *replState) handleStrategy(rest string, out io.Writer) {
+	switch rest {
+	case "":
+		fmt.Fprintln(out, strategyName(st.strategy))
+	case "value":
+		st.strategy = interp.CallByValue
+		fmt.Fprintln(out, strategyName(st.strategy))
+	case "need":
+		st.strategy = interp.CallByNeed
+		fmt.Fprintln(out, strategyName(st.strategy))
+	default:
+		fmt.Fprintln(os.Stderr, "usage: :strategy [value|need]")
+	}
+}
+
+func strategyName(s interp.EvalStrategy) string {
+	if s == interp.CallByNeed {
+		return "need"
+	}
+	return "value"
+}
+
+func (st *replState) handleLet(rest string, out io.Writer) {
+	name, expr, ok := strings.Cut(rest, "=")
+	if !ok {
+		fmt.Fprintln(os.Stderr, "usage: :let name = expr")
+		return
+	}
+	name = strings.TrimSpace(name)
+
+	ast, lerr := st.parse(expr)
+	if lerr != nil {
+		fmt.Fprintln(os.Stderr, lerr)
+		return
+	}
+
+	value, lerr := st.eval(ast)
+	if lerr != nil {
+		fmt.Fprintln(os.Stderr, lerr)
+		return
+	}
+
+	st.bind(interp.Name(name), value)
+	fmt.Fprintf(out, "%s = %s\n", name, interp.Print(value))
+}
+
+// bind adds name -> value as the new innermost binding, in whichever
+// representation this session's pipeline uses.
+func (st *replState) bind(name interp.Name, value interp.Element) {
+	if st.named {
+		prev := st.scope
+		st.scope = interp.Scope{Parent: &prev, Variable: name, Value: value}
+		return
+	}
+	st.names = append([]interp.Name{name}, st.names...)
+	st.values = append([]interp.Element{value}, st.values...)
+}
+
+func (st *replState) handleLoad(path string, out io.Writer) {
+	script, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "can't read %s: %v\n", path, err)
+		return
+	}
+	st.loadSource(path, string(script), out)
+}
+
+func (st *replState) handleShow(expr string, out io.Writer) {
+	ast, lerr := st.parse(expr)
+	if lerr != nil {
+		fmt.Fprintln(os.Stderr, lerr)
+		return
+	}
+	fmt.Fprintln(out, interp.Print(ast))
+}
+
+func (st *replState) parse(expr string) (interp.Element, *interp.LambdaError) {
+	st.line++
+	src := interp.NewSource(fmt.Sprintf("repl:%d", st.line), []byte(expr))
+	tokens := interp.Tokenise(src)
+	return interp.Treeify(tokens, src)
+}